@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// enqueueDelete buffers msg's receipt handle for batched deletion, flushing
+// immediately once the buffer reaches DeleteBatchSize.
+func (c *Consumer) enqueueDelete(msg *types.Message) {
+	c.deleteMu.Lock()
+	c.deleteEntries = append(c.deleteEntries, types.DeleteMessageBatchRequestEntry{
+		Id:            msg.MessageId,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	shouldFlush := len(c.deleteEntries) >= c.deleteBatchSize
+	c.deleteMu.Unlock()
+
+	if shouldFlush {
+		c.flushDeletes()
+	}
+}
+
+// runDeleteFlusher periodically flushes buffered deletes until deleteStop is
+// closed, performing one last flush before returning.
+func (c *Consumer) runDeleteFlusher() {
+	ticker := time.NewTicker(c.deleteFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.deleteStop:
+			c.flushDeletes()
+			return
+		case <-ticker.C:
+			c.flushDeletes()
+		}
+	}
+}
+
+// flushDeletes sends any buffered receipt handles via DeleteMessageBatch, up
+// to 10 per call, retrying entries SQS reports as failed individually.
+func (c *Consumer) flushDeletes() {
+	c.deleteMu.Lock()
+	entries := c.deleteEntries
+	c.deleteEntries = nil
+	c.deleteMu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	log := GetLogger("flushDeletes")
+
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > 10 {
+			n = 10
+		}
+		batch := entries[:n]
+		entries = entries[n:]
+
+		result, err := c.sqsClient.DeleteMessageBatch(
+			context.Background(), &sqs.DeleteMessageBatchInput{
+				QueueUrl: &c.queueUrl,
+				Entries:  batch,
+			},
+		)
+		if err != nil {
+			log.Error().Err(err).Msgf("error deleting message batch: %v", err)
+			continue
+		}
+
+		for _, failed := range result.Failed {
+			log.Error().
+				Str("MessageId", aws.ToString(failed.Id)).
+				Str("code", aws.ToString(failed.Code)).
+				Bool("senderFault", failed.SenderFault).
+				Msgf("failed to delete message from batch: %s", aws.ToString(failed.Message))
+			c.retryDelete(batch, failed)
+		}
+	}
+}
+
+// retryDelete retries, via a plain DeleteMessage call, the single entry in
+// batch that DeleteMessageBatch reported as failed.
+func (c *Consumer) retryDelete(batch []types.DeleteMessageBatchRequestEntry, failed types.BatchResultErrorEntry) {
+	log := GetLogger("retryDelete")
+
+	for _, entry := range batch {
+		if aws.ToString(entry.Id) != aws.ToString(failed.Id) {
+			continue
+		}
+
+		_, err := c.sqsClient.DeleteMessage(
+			context.Background(), &sqs.DeleteMessageInput{
+				QueueUrl:      &c.queueUrl,
+				ReceiptHandle: entry.ReceiptHandle,
+			},
+		)
+		if err != nil {
+			log.Error().Err(err).Msgf("retry delete for message %s also failed", aws.ToString(failed.Id))
+		}
+		return
+	}
+}
@@ -0,0 +1,91 @@
+package consumer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestConsumer_EnqueueDelete_FlushesAtBatchSize(t *testing.T) {
+	fake := &fakeSqsClient{}
+	c := New(Options{
+		QueueUrl:            "https://example.com/queue",
+		DeleteBatchSize:     2,
+		DeleteFlushInterval: time.Hour,
+	})
+	c.sqsClient = fake
+
+	id1, id2 := "a", "b"
+	c.enqueueDelete(&types.Message{MessageId: &id1, ReceiptHandle: &id1})
+	if got := fake.batchDeleted.Load(); got != 0 {
+		t.Fatalf("expected no flush before batch size is reached, got %d", got)
+	}
+
+	c.enqueueDelete(&types.Message{MessageId: &id2, ReceiptHandle: &id2})
+	if got := fake.batchDeleted.Load(); got != 2 {
+		t.Fatalf("expected a flush of 2 entries once batch size is reached, got %d", got)
+	}
+}
+
+func TestConsumer_RunDeleteFlusher_FlushesOnInterval(t *testing.T) {
+	fake := &fakeSqsClient{}
+	c := New(Options{
+		QueueUrl:            "https://example.com/queue",
+		DeleteBatchSize:     10,
+		DeleteFlushInterval: 10 * time.Millisecond,
+	})
+	c.sqsClient = fake
+
+	id := "a"
+	c.enqueueDelete(&types.Message{MessageId: &id, ReceiptHandle: &id})
+
+	go c.runDeleteFlusher()
+	defer close(c.deleteStop)
+
+	waitForCondition(t, time.Second, func() bool {
+		return fake.batchDeleted.Load() == 1
+	}, "expected the interval-driven flusher to flush the buffered entry")
+}
+
+func TestConsumer_FlushDeletes_RetriesPartialFailures(t *testing.T) {
+	fake := &partialFailureSqsClient{failId: "b"}
+	c := New(Options{QueueUrl: "https://example.com/queue"})
+	c.sqsClient = fake
+
+	id1, id2 := "a", "b"
+	c.enqueueDelete(&types.Message{MessageId: &id1, ReceiptHandle: &id1})
+	c.enqueueDelete(&types.Message{MessageId: &id2, ReceiptHandle: &id2})
+	c.flushDeletes()
+
+	if got := fake.retried.Load(); got != 1 {
+		t.Fatalf("expected the failed entry to be retried individually, retried=%d", got)
+	}
+}
+
+// partialFailureSqsClient reports one entry as failed out of every
+// DeleteMessageBatch call, to exercise retryDelete.
+type partialFailureSqsClient struct {
+	fakeSqsClient
+	failId  string
+	retried atomic.Int32
+}
+
+func (f *partialFailureSqsClient) DeleteMessageBatch(_ context.Context, params *sqs.DeleteMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, entry := range params.Entries {
+		if *entry.Id == f.failId {
+			code := "ReceiptHandleIsInvalid"
+			out.Failed = append(out.Failed, types.BatchResultErrorEntry{Id: entry.Id, Code: &code})
+		}
+	}
+	return out, nil
+}
+
+func (f *partialFailureSqsClient) DeleteMessage(_ context.Context, _ *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.retried.Add(1)
+	return &sqs.DeleteMessageOutput{}, nil
+}
@@ -2,8 +2,11 @@ package consumer
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,32 +16,121 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// HandlerFunc processes a single SQS message.
+type HandlerFunc func(c context.Context, m *types.Message) error
+
+// sqsAPI is the subset of *sqs.Client used by Consumer, extracted so tests
+// can supply a fake implementation.
+type sqsAPI interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+}
+
 type (
 	Consumer struct {
-		sqsClient                   *sqs.Client
+		sqsClient                   sqsAPI
 		queueUrl                    string
-		handler                     func(c context.Context, m *types.Message) error
+		handler                     HandlerFunc
 		stopSignal                  chan os.Signal
 		messagesChannel             chan types.Message
 		batchSize                   int
 		pollDelayInMs               time.Duration
 		visibilityTimeout           int
 		waitTimeSeconds             int
+		workersNum                  int
+		wg                          sync.WaitGroup
+		extendEnabled               bool
+		extendInterval              time.Duration
+		maxExtensions               int
+		onError                     func(c context.Context, m *types.Message, err error) Action
+		deadLetterQueueUrl          string
+		deleteBatchSize             int
+		deleteFlushInterval         time.Duration
+		deleteMu                    sync.Mutex
+		deleteEntries               []types.DeleteMessageBatchRequestEntry
+		deleteStop                  chan struct{}
+		deleteWG                    sync.WaitGroup
+		stopped                     chan struct{}
+		fifo                        bool
+		maxGroupConcurrency         int
+		groupSem                    chan struct{}
+		fifoMu                      sync.Mutex
+		fifoQueues                  map[string][]types.Message
+		fifoActive                  map[string]bool
 		MessageAttributeNames       []string
 		messageSystemAttributeNames []types.MessageSystemAttributeName
 		shouldDeleteMessages        bool
 		logger                      zerolog.Logger
 	}
 	Options struct {
-		QueueUrl                    string
-		SqsClient                   *sqs.Client
-		BatchSize                   int
-		PollDelayInMs               int
-		VisibilityTimeout           int
-		WaitTimeSeconds             int
+		QueueUrl string
+		// SqsClient lets callers (and tests) supply their own SQS client.
+		// Defaults to an *sqs.Client built from AWS_REGION when left nil.
+		SqsClient sqsAPI
+
+		BatchSize         int
+		PollDelayInMs     int
+		VisibilityTimeout int
+		WaitTimeSeconds   int
+		// WorkersNum controls how many goroutines concurrently pull messages
+		// off the internal channel and invoke HandleMessage. Defaults to 1.
+		WorkersNum int
+
+		// ExtendEnabled starts a per-message heartbeat that periodically
+		// calls ChangeMessageVisibility while HandleMessage is still
+		// running, so long jobs don't become visible to other consumers
+		// before VisibilityTimeout elapses.
+		ExtendEnabled bool
+		// ExtendInterval is how often the heartbeat runs. Defaults to half
+		// of VisibilityTimeout when ExtendEnabled is set and this is zero,
+		// or to defaultExtendInterval when VisibilityTimeout is also unset.
+		ExtendInterval time.Duration
+		// MaxExtensions caps how many times a single message's visibility
+		// may be extended. Zero means unlimited.
+		MaxExtensions int
+
+		// OnError decides what happens to a message whose handler returned
+		// an error (or panicked). Defaults to inspecting the error for
+		// ErrDropMessage/ErrRetryMessage and otherwise retaining the
+		// message for redelivery.
+		OnError func(c context.Context, m *types.Message, err error) Action
+		// DeadLetterQueueUrl is required for OnError to return
+		// ActionDeadLetter.
+		DeadLetterQueueUrl string
+
+		// DeleteBatchSize is how many acked receipt handles are buffered
+		// before a DeleteMessageBatch call is flushed. Defaults to 10, the
+		// AWS maximum per call.
+		DeleteBatchSize int
+		// DeleteFlushInterval forces a flush of buffered deletes even if
+		// DeleteBatchSize hasn't been reached. Defaults to 1 second.
+		DeleteFlushInterval time.Duration
+
+		// FIFO enables per-MessageGroupId ordering: messages sharing a
+		// group are processed strictly serially, while different groups
+		// run concurrently. Detected automatically when QueueUrl ends in
+		// ".fifo"; set this to enable it for a queue URL that doesn't.
+		FIFO bool
+		// MaxGroupConcurrency bounds how many distinct MessageGroupIds are
+		// drained at once when FIFO is enabled. Defaults to
+		// defaultMaxGroupConcurrency, independent of WorkersNum (the FIFO
+		// dispatcher spawns its own per-group goroutines rather than using
+		// the worker pool), so cross-group processing is parallel out of
+		// the box.
+		MaxGroupConcurrency int
+
+		// Middlewares wrap HandleMessage, applied outermost-first: the first
+		// entry's code runs first and last, like a standard HTTP middleware
+		// chain. See WithLogging, WithMetrics, WithTimeout and WithTracing
+		// for the built-in middlewares.
+		Middlewares []Middleware
+
 		MessageAttributeNames       []string
 		MessageSystemAttributeNames []types.MessageSystemAttributeName
-		HandleMessage               func(c context.Context, m *types.Message) error
+		HandleMessage               HandlerFunc
 		ShouldDeleteMessages        aws.Ternary
 	}
 )
@@ -48,17 +140,47 @@ var (
 	region        = os.Getenv("AWS_REGION")
 )
 
+// defaultExtendInterval is used when ExtendEnabled is set but neither
+// ExtendInterval nor VisibilityTimeout give us a positive value to derive
+// one from.
+const defaultExtendInterval = 30 * time.Second
+
+// defaultMaxGroupConcurrency is used when FIFO is enabled but
+// MaxGroupConcurrency is left unset, so distinct MessageGroupIds are
+// drained in parallel by default instead of silently serializing on a
+// WorkersNum of 1.
+const defaultMaxGroupConcurrency = 10
+
 func New(o Options) *Consumer {
+	handler := o.HandleMessage
+	for i := len(o.Middlewares) - 1; i >= 0; i-- {
+		handler = o.Middlewares[i](handler)
+	}
+
 	c := Consumer{
 		queueUrl:                    o.QueueUrl,
 		sqsClient:                   o.SqsClient,
-		handler:                     o.HandleMessage,
+		handler:                     handler,
 		stopSignal:                  make(chan os.Signal, 1),
 		messagesChannel:             make(chan types.Message),
 		batchSize:                   o.BatchSize,
 		pollDelayInMs:               time.Duration(o.PollDelayInMs) * time.Millisecond,
 		visibilityTimeout:           o.VisibilityTimeout,
 		waitTimeSeconds:             o.WaitTimeSeconds,
+		workersNum:                  o.WorkersNum,
+		extendEnabled:               o.ExtendEnabled,
+		extendInterval:              o.ExtendInterval,
+		maxExtensions:               o.MaxExtensions,
+		onError:                     o.OnError,
+		deadLetterQueueUrl:          o.DeadLetterQueueUrl,
+		deleteBatchSize:             o.DeleteBatchSize,
+		deleteFlushInterval:         o.DeleteFlushInterval,
+		deleteStop:                  make(chan struct{}),
+		stopped:                     make(chan struct{}),
+		fifo:                        strings.HasSuffix(o.QueueUrl, ".fifo") || o.FIFO,
+		maxGroupConcurrency:         o.MaxGroupConcurrency,
+		fifoQueues:                  make(map[string][]types.Message),
+		fifoActive:                  make(map[string]bool),
 		MessageAttributeNames:       o.MessageAttributeNames,
 		messageSystemAttributeNames: o.MessageSystemAttributeNames,
 		shouldDeleteMessages:        true,
@@ -92,6 +214,54 @@ func New(o Options) *Consumer {
 		c.shouldDeleteMessages = o.ShouldDeleteMessages.Bool()
 	}
 
+	// If workers number not set use default 1
+	if c.workersNum == 0 {
+		c.workersNum = 1
+	}
+
+	// If extension is enabled but no interval was given, extend halfway
+	// through the visibility timeout. VisibilityTimeout may be left unset,
+	// so fall back to a safe positive default rather than handing
+	// time.NewTicker a non-positive duration.
+	if c.extendEnabled && c.extendInterval <= 0 {
+		c.extendInterval = time.Duration(c.visibilityTimeout) * time.Second / 2
+		if c.extendInterval <= 0 {
+			c.extendInterval = defaultExtendInterval
+		}
+	}
+
+	// AWS batch size limit of 10,
+	// https//docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/quotas-messages.html
+	if c.deleteBatchSize == 0 {
+		c.deleteBatchSize = 10
+	}
+	if c.deleteBatchSize > 10 {
+		c.deleteBatchSize = 10
+	}
+	if c.deleteFlushInterval == 0 {
+		c.deleteFlushInterval = time.Second
+	}
+
+	if c.fifo {
+		// Processing order for a group is only guaranteed if SQS tells us
+		// which group each message belongs to.
+		hasGroupId := false
+		for _, a := range c.messageSystemAttributeNames {
+			if a == types.MessageSystemAttributeNameMessageGroupId {
+				hasGroupId = true
+				break
+			}
+		}
+		if !hasGroupId {
+			c.messageSystemAttributeNames = append(c.messageSystemAttributeNames, types.MessageSystemAttributeNameMessageGroupId)
+		}
+
+		if c.maxGroupConcurrency == 0 {
+			c.maxGroupConcurrency = defaultMaxGroupConcurrency
+		}
+		c.groupSem = make(chan struct{}, c.maxGroupConcurrency)
+	}
+
 	return &c
 }
 
@@ -104,30 +274,42 @@ func (c *Consumer) Start() {
 
 	signal.Notify(c.stopSignal, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start message processing in a single goroutine
-	go c.processMessages()
+	if c.fifo {
+		// Concurrency is governed by per-MessageGroupId goroutines spawned
+		// from dispatchFifo, bounded by groupSem, instead of a fixed pool.
+		log.Debug().Msgf("FIFO mode enabled, max %d groups processed concurrently", c.maxGroupConcurrency)
+	} else {
+		// Start a pool of workers that share messagesChannel, so handler
+		// invocations run concurrently instead of serializing a whole batch.
+		for i := 0; i < c.workersNum; i++ {
+			c.wg.Add(1)
+			go func() {
+				defer c.wg.Done()
+				c.processMessages()
+			}()
+		}
+	}
+
+	// Start the batched deleter that flushes acked receipt handles on a
+	// timer or once DeleteBatchSize is reached.
+	c.deleteWG.Add(1)
+	go func() {
+		defer c.deleteWG.Done()
+		c.runDeleteFlusher()
+	}()
 
-	// Poll messages in a loop
+	// Poll messages in a loop; blocks until a stop signal is received or
+	// ReceiveMessage returns an error
 	c.pollMessages()
 
-	// Wait for stop signal
-	<-c.stopSignal
 	c.logger.Debug().Msgf("Shutdown signal received. Stopping...")
 	close(c.messagesChannel)
-}
+	c.wg.Wait()
 
-// waitForProcessing ensures that all messages in the current batch are processed before fetching new messages.
-func (c *Consumer) waitForProcessing() {
-	// Wait for the message channel to drain
-	for {
-		// If the message channel is empty, wait for pollDelay before re-checking
-		if len(c.messagesChannel) == 0 {
-			time.Sleep(c.pollDelayInMs)
-			return
-		}
-		// If the message channel is not empty, wait for it to drain
-		time.Sleep(100 * time.Millisecond) // Small delay to re-check channel status
-	}
+	close(c.deleteStop)
+	c.deleteWG.Wait()
+
+	close(c.stopped)
 }
 
 func (c *Consumer) pollMessages() {
@@ -136,7 +318,6 @@ func (c *Consumer) pollMessages() {
 		select {
 		case <-c.stopSignal:
 			log.Debug().Msgf("stop signal received, shutting down message receiver")
-			close(c.messagesChannel)
 			return
 		default:
 			result, err := c.sqsClient.ReceiveMessage(
@@ -156,55 +337,84 @@ func (c *Consumer) pollMessages() {
 				return
 			}
 			log.Debug().Interface("result", result).Msgf("pollMessages.result")
-			if len(result.Messages) > 0 {
-				for _, message := range result.Messages {
+			// Hand messages off for processing and go straight back to
+			// polling, so receiving and processing overlap.
+			for _, message := range result.Messages {
+				if c.fifo {
+					c.dispatchFifo(message)
+				} else {
 					c.messagesChannel <- message
 				}
 			}
 
-			c.waitForProcessing()
+			if len(result.Messages) == 0 && c.pollDelayInMs > 0 {
+				time.Sleep(c.pollDelayInMs)
+			}
 		}
 	}
 }
 
 func (c *Consumer) processMessages() {
 	ctx := Logger.WithContext(context.Background())
-	log := GetLogger("processMessages")
 	for msg := range c.messagesChannel {
-		err := c.handler(ctx, &msg)
-		if err != nil {
-			log.Error().Err(err).
-				Interface("message", msg).
-				Msgf("Error processing message: %v\n", err)
-			continue
-		}
+		c.processOne(ctx, &msg)
+	}
+}
 
-		// Delete the message from SQS after successful processing
-		if c.shouldDeleteMessages {
-			go c.deleteMessage(ctx, &msg)
-		}
+// processOne runs the full per-message pipeline: visibility extension,
+// handler invocation, and the resulting delete/retention action. Shared by
+// the regular worker pool and the per-group FIFO drainers.
+func (c *Consumer) processOne(ctx context.Context, msg *types.Message) {
+	log := GetLogger("processMessages")
+
+	stopExtending := c.startVisibilityExtender(msg)
+	err := c.callHandler(ctx, msg)
+	stopExtending()
+	if err != nil {
+		log.Error().Err(err).
+			Interface("message", msg).
+			Msgf("Error processing message: %v\n", err)
+		c.handleError(ctx, msg, err)
+		return
+	}
+
+	// Buffer the message for batched deletion after successful processing
+	if c.shouldDeleteMessages {
+		c.enqueueDelete(msg)
 	}
 }
 
-// Stop gracefully shuts down the consumer.
+// callHandler invokes the handler, recovering a panic into an error so a
+// single bad message can't crash the whole consumer.
+func (c *Consumer) callHandler(ctx context.Context, msg *types.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in handler: %v", r)
+		}
+	}()
+	return c.handler(ctx, msg)
+}
+
+// Stop gracefully shuts down the consumer: it signals pollMessages to stop,
+// then blocks until Start's full teardown completes, including draining
+// in-flight handlers and flushing any buffered deletes. Start must be
+// running (in its own goroutine) for Stop to return.
 func (c *Consumer) Stop() {
 	close(c.stopSignal)
+	<-c.stopped
 }
 
 func (c *Consumer) deleteMessage(ctx context.Context, msg *types.Message) {
 	log := zerolog.Ctx(ctx).With().Str("MessageId", *msg.MessageId).
 		Str("component", "deleteMessage").Logger()
-	// Delete the message from SQS after successful processing
-	if c.shouldDeleteMessages {
-		log.Debug().Msgf("deleting message %s", *msg.MessageId)
-		_, err := c.sqsClient.DeleteMessage(
-			context.Background(), &sqs.DeleteMessageInput{
-				QueueUrl:      &c.queueUrl,
-				ReceiptHandle: msg.ReceiptHandle,
-			},
-		)
-		if err != nil {
-			log.Error().Err(err).Msgf("error deleting message %v", err)
-		}
+	log.Debug().Msgf("deleting message %s", *msg.MessageId)
+	_, err := c.sqsClient.DeleteMessage(
+		context.Background(), &sqs.DeleteMessageInput{
+			QueueUrl:      &c.queueUrl,
+			ReceiptHandle: msg.ReceiptHandle,
+		},
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("error deleting message %v", err)
 	}
 }
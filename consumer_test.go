@@ -0,0 +1,176 @@
+package consumer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// fakeSqsClient implements sqsAPI and returns a fixed batch of messages once,
+// then empty results, so each message is delivered only once during a test.
+type fakeSqsClient struct {
+	messages     []types.Message
+	served       atomic.Bool
+	deleted      atomic.Int32
+	batchDeleted atomic.Int32
+	extended     atomic.Int32
+	extendErr    error
+	sent         atomic.Int32
+}
+
+func (f *fakeSqsClient) ReceiveMessage(_ context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if f.served.CompareAndSwap(false, true) {
+		return &sqs.ReceiveMessageOutput{Messages: f.messages}, nil
+	}
+	return &sqs.ReceiveMessageOutput{}, nil
+}
+
+func (f *fakeSqsClient) DeleteMessage(_ context.Context, _ *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.deleted.Add(1)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSqsClient) ChangeMessageVisibility(_ context.Context, _ *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	if f.extendErr != nil {
+		return nil, f.extendErr
+	}
+	f.extended.Add(1)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeSqsClient) SendMessage(_ context.Context, _ *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.sent.Add(1)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSqsClient) DeleteMessageBatch(_ context.Context, params *sqs.DeleteMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	f.batchDeleted.Add(int32(len(params.Entries)))
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+func newTestMessages(n int) []types.Message {
+	msgs := make([]types.Message, n)
+	for i := range msgs {
+		id := string(rune('a' + i))
+		msgs[i] = types.Message{
+			MessageId:     &id,
+			ReceiptHandle: &id,
+		}
+	}
+	return msgs
+}
+
+// TestConsumer_WorkerPool_ProcessesConcurrently verifies that with
+// WorkersNum > 1 handler invocations overlap instead of serializing.
+func TestConsumer_WorkerPool_ProcessesConcurrently(t *testing.T) {
+	const workers = 5
+	fake := &fakeSqsClient{messages: newTestMessages(workers)}
+
+	var inFlight, maxInFlight atomic.Int32
+	handled := make(chan struct{}, workers)
+
+	c := New(Options{
+		QueueUrl:   "https://example.com/queue",
+		SqsClient:  nil,
+		WorkersNum: workers,
+		HandleMessage: func(_ context.Context, _ *types.Message) error {
+			n := inFlight.Add(1)
+			for {
+				cur := maxInFlight.Load()
+				if n <= cur || maxInFlight.CompareAndSwap(cur, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			inFlight.Add(-1)
+			handled <- struct{}{}
+			return nil
+		},
+	})
+	c.sqsClient = fake
+
+	go c.Start()
+
+	for i := 0; i < workers; i++ {
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for message %d to be handled", i)
+		}
+	}
+
+	c.Stop()
+
+	if got := maxInFlight.Load(); got < 2 {
+		t.Fatalf("expected handler invocations to overlap, max concurrent was %d", got)
+	}
+	waitForCondition(t, time.Second, func() bool {
+		return fake.batchDeleted.Load() == int32(workers)
+	}, "expected all processed messages to be flushed via DeleteMessageBatch")
+}
+
+// waitForCondition polls cond until it returns true or timeout elapses.
+func waitForCondition(t *testing.T, timeout time.Duration, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
+
+// TestConsumer_VisibilityExtender_ExtendsWhileHandlerRuns verifies that a
+// slow handler gets its message's visibility extended while it's running,
+// and that the extender stops once the handler returns.
+func TestConsumer_VisibilityExtender_ExtendsWhileHandlerRuns(t *testing.T) {
+	fake := &fakeSqsClient{messages: newTestMessages(1)}
+	handled := make(chan struct{})
+
+	c := New(Options{
+		QueueUrl:          "https://example.com/queue",
+		VisibilityTimeout: 1,
+		ExtendEnabled:     true,
+		ExtendInterval:    20 * time.Millisecond,
+		HandleMessage: func(_ context.Context, _ *types.Message) error {
+			time.Sleep(100 * time.Millisecond)
+			close(handled)
+			return nil
+		},
+	})
+	c.sqsClient = fake
+
+	go c.Start()
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for handler to run")
+	}
+
+	c.Stop()
+
+	if got := fake.extended.Load(); got < 2 {
+		t.Fatalf("expected at least 2 visibility extensions while handler ran, got %d", got)
+	}
+}
+
+// TestNew_DefaultsSqsClientWhenOmitted verifies that New builds a default
+// *sqs.Client when Options.SqsClient is left unset, rather than leaving
+// sqsClient as a nil interface.
+func TestNew_DefaultsSqsClientWhenOmitted(t *testing.T) {
+	c := New(Options{QueueUrl: "https://example.com/queue"})
+
+	if c.sqsClient == nil {
+		t.Fatal("expected New to create a default sqsClient, got nil")
+	}
+	if _, ok := c.sqsClient.(*sqs.Client); !ok {
+		t.Fatalf("expected default sqsClient to be *sqs.Client, got %T", c.sqsClient)
+	}
+}
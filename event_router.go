@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// EventHandlerFunc processes a single CloudEvent matched to its registered
+// event type.
+type EventHandlerFunc func(ctx context.Context, event *cloudevents.Event) error
+
+// EventRouter dispatches SQS messages carrying a CloudEvents envelope to
+// handlers registered per event type. Construct one with NewEventRouter and
+// plug its HandleMessage method into Options.HandleMessage.
+type EventRouter struct {
+	handlers       map[string]EventHandlerFunc
+	defaultHandler EventHandlerFunc
+}
+
+// NewEventRouter creates an EventRouter. defaultHandler is invoked for event
+// types with no registered handler; it may be nil, in which case unmatched
+// messages are acked (deleted) without being handled.
+func NewEventRouter(defaultHandler EventHandlerFunc) *EventRouter {
+	return &EventRouter{
+		handlers:       make(map[string]EventHandlerFunc),
+		defaultHandler: defaultHandler,
+	}
+}
+
+// RegisterHandler registers fn to handle CloudEvents of the given type.
+func (r *EventRouter) RegisterHandler(eventType string, fn EventHandlerFunc) {
+	r.handlers[eventType] = fn
+}
+
+// HandleMessage parses msg as a CloudEvents envelope and dispatches it to
+// the handler registered for its event type, falling back to the default
+// handler. It satisfies the func signature expected by Options.HandleMessage.
+func (r *EventRouter) HandleMessage(ctx context.Context, msg *types.Message) error {
+	event, err := parseCloudEvent(msg)
+	if err != nil {
+		return err
+	}
+
+	handler, ok := r.handlers[event.Type()]
+	if !ok {
+		handler = r.defaultHandler
+	}
+	if handler == nil {
+		// No handler matched and no default set: ack the message.
+		return nil
+	}
+
+	return handler(ctx, event)
+}
+
+// parseCloudEvent reads msg as a structured-mode CloudEvents JSON envelope,
+// falling back to binary mode where ce_type/ce_source/ce_id travel in
+// MessageAttributes and the body is the raw event data.
+func parseCloudEvent(msg *types.Message) (*cloudevents.Event, error) {
+	if msg.Body != nil {
+		event := cloudevents.NewEvent()
+		if err := json.Unmarshal([]byte(*msg.Body), &event); err == nil && event.Type() != "" {
+			return &event, nil
+		}
+	}
+
+	event := cloudevents.NewEvent()
+	if attr, ok := msg.MessageAttributes["ce_type"]; ok {
+		event.SetType(aws.ToString(attr.StringValue))
+	}
+	if attr, ok := msg.MessageAttributes["ce_source"]; ok {
+		event.SetSource(aws.ToString(attr.StringValue))
+	}
+	if attr, ok := msg.MessageAttributes["ce_id"]; ok {
+		event.SetID(aws.ToString(attr.StringValue))
+	}
+	if msg.Body != nil {
+		if err := event.SetData(cloudevents.ApplicationJSON, []byte(*msg.Body)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &event, nil
+}
@@ -0,0 +1,72 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func structuredEventMessage(t *testing.T, eventType string) *types.Message {
+	t.Helper()
+
+	event := cloudevents.NewEvent()
+	event.SetID("evt-1")
+	event.SetSource("test")
+	event.SetType(eventType)
+	if err := event.SetData(cloudevents.ApplicationJSON, map[string]string{"k": "v"}); err != nil {
+		t.Fatalf("SetData: %v", err)
+	}
+
+	body, err := event.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	bodyStr := string(body)
+
+	return &types.Message{Body: &bodyStr}
+}
+
+func TestEventRouter_DispatchesToRegisteredHandler(t *testing.T) {
+	router := NewEventRouter(nil)
+
+	var got string
+	router.RegisterHandler("order.created", func(_ context.Context, event *cloudevents.Event) error {
+		got = event.Type()
+		return nil
+	})
+
+	msg := structuredEventMessage(t, "order.created")
+	if err := router.HandleMessage(context.Background(), msg); err != nil {
+		t.Fatalf("HandleMessage: %v", err)
+	}
+	if got != "order.created" {
+		t.Fatalf("expected handler to receive order.created, got %q", got)
+	}
+}
+
+func TestEventRouter_FallsBackToDefaultHandler(t *testing.T) {
+	var gotDefault bool
+	router := NewEventRouter(func(_ context.Context, event *cloudevents.Event) error {
+		gotDefault = true
+		return nil
+	})
+
+	msg := structuredEventMessage(t, "unhandled.type")
+	if err := router.HandleMessage(context.Background(), msg); err != nil {
+		t.Fatalf("HandleMessage: %v", err)
+	}
+	if !gotDefault {
+		t.Fatal("expected default handler to run for an unmatched event type")
+	}
+}
+
+func TestEventRouter_AcksWhenNoHandlerAndNoDefault(t *testing.T) {
+	router := NewEventRouter(nil)
+
+	msg := structuredEventMessage(t, "unhandled.type")
+	if err := router.HandleMessage(context.Background(), msg); err != nil {
+		t.Fatalf("expected nil error (ack) when no handler matches, got %v", err)
+	}
+}
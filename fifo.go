@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// dispatchFifo buffers msg under its MessageGroupId and, if no goroutine is
+// already draining that group, starts one. This guarantees messages sharing
+// a group are handled strictly in order by a single goroutine at a time,
+// while distinct groups are drained concurrently up to MaxGroupConcurrency.
+func (c *Consumer) dispatchFifo(msg types.Message) {
+	group := msg.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+
+	c.fifoMu.Lock()
+	c.fifoQueues[group] = append(c.fifoQueues[group], msg)
+	alreadyDraining := c.fifoActive[group]
+	c.fifoActive[group] = true
+	c.fifoMu.Unlock()
+
+	if alreadyDraining {
+		return
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.drainFifoGroup(group)
+	}()
+}
+
+// drainFifoGroup processes every message buffered for group, in order, one
+// at a time, until its queue is empty. It holds a slot in groupSem for its
+// entire run so at most MaxGroupConcurrency groups are active at once.
+func (c *Consumer) drainFifoGroup(group string) {
+	c.groupSem <- struct{}{}
+	defer func() { <-c.groupSem }()
+
+	ctx := Logger.WithContext(context.Background())
+
+	for {
+		c.fifoMu.Lock()
+		queue := c.fifoQueues[group]
+		if len(queue) == 0 {
+			c.fifoActive[group] = false
+			delete(c.fifoQueues, group)
+			c.fifoMu.Unlock()
+			return
+		}
+		msg := queue[0]
+		c.fifoQueues[group] = queue[1:]
+		c.fifoMu.Unlock()
+
+		c.processOne(ctx, &msg)
+	}
+}
@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func fifoTestMessages(groups, perGroup int) []types.Message {
+	var msgs []types.Message
+	for g := 0; g < groups; g++ {
+		group := fmt.Sprintf("group-%d", g)
+		for i := 0; i < perGroup; i++ {
+			id := fmt.Sprintf("%s-%d", group, i)
+			msgs = append(msgs, types.Message{
+				MessageId:     &id,
+				ReceiptHandle: &id,
+				Attributes: map[string]string{
+					string(types.MessageSystemAttributeNameMessageGroupId): group,
+					"seq": strconv.Itoa(i),
+				},
+			})
+		}
+	}
+	return msgs
+}
+
+// TestConsumer_FIFO_PreservesPerGroupOrder verifies that, even with a large
+// worker/group concurrency budget, messages sharing a MessageGroupId are
+// always handled in the order they were received.
+func TestConsumer_FIFO_PreservesPerGroupOrder(t *testing.T) {
+	const groups = 5
+	const perGroup = 20
+
+	messages := fifoTestMessages(groups, perGroup)
+	fake := &fakeSqsClient{messages: messages}
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	var handledCount int
+	done := make(chan struct{})
+
+	c := New(Options{
+		QueueUrl:            "https://example.com/queue.fifo",
+		MaxGroupConcurrency: groups * 4,
+		HandleMessage: func(_ context.Context, m *types.Message) error {
+			group := m.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+			seq, _ := strconv.Atoi(m.Attributes["seq"])
+
+			// Jitter to encourage interleaving across groups if ordering
+			// weren't actually enforced per group.
+			time.Sleep(time.Duration(seq%3) * time.Millisecond)
+
+			mu.Lock()
+			seen[group] = append(seen[group], seq)
+			handledCount++
+			n := handledCount
+			mu.Unlock()
+
+			if n == groups*perGroup {
+				close(done)
+			}
+			return nil
+		},
+	})
+	c.sqsClient = fake
+
+	go c.Start()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for all messages to be handled")
+	}
+
+	c.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for group, seqs := range seen {
+		if len(seqs) != perGroup {
+			t.Fatalf("group %s: got %d messages, want %d", group, len(seqs), perGroup)
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("group %s: out-of-order delivery, got sequence %v", group, seqs)
+			}
+		}
+	}
+}
+
+func TestNew_DetectsFifoFromQueueUrlSuffix(t *testing.T) {
+	c := New(Options{QueueUrl: "https://example.com/my-queue.fifo"})
+	if !c.fifo {
+		t.Fatal("expected a .fifo queue URL to enable FIFO mode")
+	}
+
+	found := false
+	for _, a := range c.messageSystemAttributeNames {
+		if a == types.MessageSystemAttributeNameMessageGroupId {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected FIFO mode to request the MessageGroupId system attribute")
+	}
+}
@@ -0,0 +1,128 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Middleware wraps a HandlerFunc to add cross-cutting behavior such as
+// logging, metrics or tracing. Options.Middlewares are applied
+// outermost-first: the first entry's code runs first and last, like a
+// standard HTTP middleware chain.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// WithLogging logs a structured entry with MessageId, duration and outcome
+// for every message handled.
+func WithLogging(next HandlerFunc) HandlerFunc {
+	return func(c context.Context, m *types.Message) error {
+		log := zerolog.Ctx(c).With().Str("component", "WithLogging").
+			Str("MessageId", aws.ToString(m.MessageId)).Logger()
+
+		start := time.Now()
+		err := next(c, m)
+		duration := time.Since(start)
+
+		event := log.Info()
+		if err != nil {
+			event = log.Error().Err(err)
+		}
+		event.Dur("duration", duration).Msgf("handled message")
+
+		return err
+	}
+}
+
+// registerOrGet registers c with reg, or, if a collector with the same
+// fully-qualified name is already registered, returns the existing one
+// instead. This lets WithMetrics be called more than once against the same
+// Registerer (e.g. several consumers sharing prometheus.DefaultRegisterer)
+// without panicking on duplicate registration.
+func registerOrGet(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// WithMetrics registers received/processed/failed counters and a latency
+// histogram with reg and returns a Middleware that records them. Collectors
+// are created fresh per call and registered via registerOrGet, so calling
+// WithMetrics again against the same reg reuses the existing collectors
+// instead of panicking.
+func WithMetrics(reg prometheus.Registerer) Middleware {
+	messagesReceivedTotal := registerOrGet(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sqs_consumer_messages_received_total",
+		Help: "Total number of messages dispatched to the handler.",
+	})).(prometheus.Counter)
+	messagesProcessedTotal := registerOrGet(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sqs_consumer_messages_processed_total",
+		Help: "Total number of messages processed successfully.",
+	})).(prometheus.Counter)
+	messagesFailedTotal := registerOrGet(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sqs_consumer_messages_failed_total",
+		Help: "Total number of messages whose handler returned an error.",
+	})).(prometheus.Counter)
+	messageLatencySeconds := registerOrGet(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "sqs_consumer_message_latency_seconds",
+		Help: "Handler latency in seconds.",
+	})).(prometheus.Histogram)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c context.Context, m *types.Message) error {
+			messagesReceivedTotal.Inc()
+
+			start := time.Now()
+			err := next(c, m)
+			messageLatencySeconds.Observe(time.Since(start).Seconds())
+
+			if err != nil {
+				messagesFailedTotal.Inc()
+			} else {
+				messagesProcessedTotal.Inc()
+			}
+
+			return err
+		}
+	}
+}
+
+// WithTimeout derives a per-message context.WithTimeout around next.
+func WithTimeout(d time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c context.Context, m *types.Message) error {
+			ctx, cancel := context.WithTimeout(c, d)
+			defer cancel()
+			return next(ctx, m)
+		}
+	}
+}
+
+// WithTracing starts an OpenTelemetry span for the message, tagging it with
+// the AWS X-Ray trace header from MessageSystemAttributes when present. SQS
+// only returns that attribute if it was requested, so the caller must add
+// types.MessageSystemAttributeNameAWSTraceHeader to
+// Options.MessageSystemAttributeNames for the tag to ever be set.
+func WithTracing(next HandlerFunc) HandlerFunc {
+	tracer := otel.Tracer("github.com/1xtr/go-sqs-consumer")
+
+	return func(c context.Context, m *types.Message) error {
+		ctx, span := tracer.Start(c, "HandleMessage")
+		defer span.End()
+
+		if traceHeader, ok := m.Attributes[string(types.MessageSystemAttributeNameAWSTraceHeader)]; ok {
+			span.SetAttributes(attribute.String("aws.trace_header", traceHeader))
+		}
+
+		return next(ctx, m)
+	}
+}
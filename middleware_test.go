@@ -0,0 +1,132 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNew_ComposesMiddlewaresOutermostFirst(t *testing.T) {
+	var order []string
+
+	mw := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c context.Context, m *types.Message) error {
+				order = append(order, name+":before")
+				err := next(c, m)
+				order = append(order, name+":after")
+				return err
+			}
+		}
+	}
+
+	c := New(Options{
+		QueueUrl:    "https://example.com/queue",
+		Middlewares: []Middleware{mw("outer"), mw("inner")},
+		HandleMessage: func(_ context.Context, _ *types.Message) error {
+			order = append(order, "handler")
+			return nil
+		},
+	})
+
+	if err := c.handler(context.Background(), &types.Message{}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+
+	want := []string{"outer:before", "inner:before", "handler", "inner:after", "outer:after"}
+	if len(order) != len(want) {
+		t.Fatalf("got order %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("got order %v, want %v", order, want)
+		}
+	}
+}
+
+func TestWithTimeout_CancelsContextForHandler(t *testing.T) {
+	handler := WithTimeout(10 * time.Millisecond)(func(ctx context.Context, _ *types.Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	err := handler(context.Background(), &types.Message{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWithLogging_PassesThroughResultAndError(t *testing.T) {
+	wantErr := errors.New("boom")
+	handler := WithLogging(func(_ context.Context, _ *types.Message) error {
+		return wantErr
+	})
+
+	if err := handler(context.Background(), &types.Message{}); !errors.Is(err, wantErr) {
+		t.Fatalf("expected WithLogging to pass through the handler error, got %v", err)
+	}
+}
+
+// gatherCounterValue returns the current value of the counter named name
+// registered with reg.
+func gatherCounterValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		return f.GetMetric()[0].GetCounter().GetValue()
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
+
+func TestWithMetrics_RecordsOutcome(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	handler := WithMetrics(reg)(func(_ context.Context, _ *types.Message) error {
+		return nil
+	})
+
+	if err := handler(context.Background(), &types.Message{}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+
+	if got := gatherCounterValue(t, reg, "sqs_consumer_messages_processed_total"); got != 1 {
+		t.Fatalf("expected 1 processed message recorded, got %v", got)
+	}
+}
+
+// TestWithMetrics_SameRegistryTwiceDoesNotPanic guards against the metrics
+// collectors being package-level globals: registering a second consumer's
+// middleware against a registry that already has one must reuse the
+// existing collectors instead of panicking on duplicate registration.
+func TestWithMetrics_SameRegistryTwiceDoesNotPanic(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	first := WithMetrics(reg)(func(_ context.Context, _ *types.Message) error {
+		return nil
+	})
+	second := WithMetrics(reg)(func(_ context.Context, _ *types.Message) error {
+		return nil
+	})
+
+	if err := first(context.Background(), &types.Message{}); err != nil {
+		t.Fatalf("first handler: %v", err)
+	}
+	if err := second(context.Background(), &types.Message{}); err != nil {
+		t.Fatalf("second handler: %v", err)
+	}
+
+	if got := gatherCounterValue(t, reg, "sqs_consumer_messages_processed_total"); got != 2 {
+		t.Fatalf("expected 2 processed messages recorded across both middlewares, got %v", got)
+	}
+}
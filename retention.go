@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog"
+)
+
+// ErrDropMessage can be returned (or wrapped) by a handler to signal that
+// the message should be deleted even though processing failed.
+var ErrDropMessage = errors.New("consumer: drop message")
+
+// ErrRetryMessage can be returned (or wrapped) by a handler to signal that
+// the message should be retained so SQS redelivers it.
+var ErrRetryMessage = errors.New("consumer: retry message")
+
+// Action tells the consumer what to do with a message whose handler
+// returned an error.
+type Action int
+
+const (
+	// ActionRetain leaves the message on the queue; SQS redelivers it once
+	// the visibility timeout elapses. This is the default.
+	ActionRetain Action = iota
+	// ActionDelete removes the message from the queue despite the error.
+	ActionDelete
+	// ActionDeadLetter sends the message to DeadLetterQueueUrl and then
+	// removes it from the source queue.
+	ActionDeadLetter
+)
+
+// handleError resolves the retention policy for a failed message via
+// OnError, falling back to ErrDropMessage/ErrRetryMessage sentinels and
+// otherwise retaining the message, then carries out the resulting action.
+func (c *Consumer) handleError(ctx context.Context, msg *types.Message, handlerErr error) {
+	log := GetLogger("handleError")
+
+	action := ActionRetain
+	switch {
+	case c.onError != nil:
+		action = c.onError(ctx, msg, handlerErr)
+	case errors.Is(handlerErr, ErrDropMessage):
+		action = ActionDelete
+	case errors.Is(handlerErr, ErrRetryMessage):
+		action = ActionRetain
+	}
+
+	switch action {
+	case ActionDelete:
+		c.deleteMessage(ctx, msg)
+	case ActionDeadLetter:
+		c.sendToDeadLetter(ctx, msg)
+	default:
+		log.Debug().Msgf("retaining message %s for redelivery", aws.ToString(msg.MessageId))
+	}
+}
+
+// sendToDeadLetter forwards msg to DeadLetterQueueUrl and, on success,
+// removes it from the source queue. If DeadLetterQueueUrl is unset or the
+// send fails, the message is left in place for redelivery.
+func (c *Consumer) sendToDeadLetter(ctx context.Context, msg *types.Message) {
+	log := zerolog.Ctx(ctx).With().Str("MessageId", aws.ToString(msg.MessageId)).
+		Str("component", "deadLetter").Logger()
+
+	if c.deadLetterQueueUrl == "" {
+		log.Warn().Msgf("ActionDeadLetter requested but DeadLetterQueueUrl is not set, retaining message")
+		return
+	}
+
+	_, err := c.sqsClient.SendMessage(
+		context.Background(), &sqs.SendMessageInput{
+			QueueUrl:          &c.deadLetterQueueUrl,
+			MessageBody:       msg.Body,
+			MessageAttributes: msg.MessageAttributes,
+		},
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("error sending message to dead letter queue, retaining message")
+		return
+	}
+
+	log.Debug().Msgf("sent message to dead letter queue")
+	c.deleteMessage(ctx, msg)
+}
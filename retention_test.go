@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestConsumer_CallHandler_RecoversPanic(t *testing.T) {
+	c := New(Options{
+		QueueUrl: "https://example.com/queue",
+		HandleMessage: func(_ context.Context, _ *types.Message) error {
+			panic("boom")
+		},
+	})
+
+	err := c.callHandler(context.Background(), &types.Message{})
+	if err == nil {
+		t.Fatal("expected callHandler to convert a panic into an error")
+	}
+}
+
+func TestConsumer_HandleError_DropSentinelDeletes(t *testing.T) {
+	fake := &fakeSqsClient{}
+	c := New(Options{QueueUrl: "https://example.com/queue"})
+	c.sqsClient = fake
+
+	id := "msg-1"
+	c.handleError(context.Background(), &types.Message{MessageId: &id, ReceiptHandle: &id}, ErrDropMessage)
+
+	if got := fake.deleted.Load(); got != 1 {
+		t.Fatalf("expected ErrDropMessage to delete the message, deleted=%d", got)
+	}
+}
+
+func TestConsumer_HandleError_RetrySentinelRetains(t *testing.T) {
+	fake := &fakeSqsClient{}
+	c := New(Options{QueueUrl: "https://example.com/queue"})
+	c.sqsClient = fake
+
+	id := "msg-1"
+	c.handleError(context.Background(), &types.Message{MessageId: &id, ReceiptHandle: &id}, ErrRetryMessage)
+
+	if got := fake.deleted.Load(); got != 0 {
+		t.Fatalf("expected ErrRetryMessage to retain the message, deleted=%d", got)
+	}
+}
+
+func TestConsumer_HandleError_OnErrorDeadLetters(t *testing.T) {
+	fake := &fakeSqsClient{}
+	c := New(Options{
+		QueueUrl:           "https://example.com/queue",
+		DeadLetterQueueUrl: "https://example.com/dlq",
+		OnError: func(_ context.Context, _ *types.Message, _ error) Action {
+			return ActionDeadLetter
+		},
+	})
+	c.sqsClient = fake
+
+	id := "msg-1"
+	c.handleError(context.Background(), &types.Message{MessageId: &id, ReceiptHandle: &id}, errors.New("boom"))
+
+	if got := fake.sent.Load(); got != 1 {
+		t.Fatalf("expected message to be sent to the dead letter queue, sent=%d", got)
+	}
+	if got := fake.deleted.Load(); got != 1 {
+		t.Fatalf("expected message to be deleted from the source queue after dead-lettering, deleted=%d", got)
+	}
+}
+
+func TestConsumer_PanicInHandler_DoesNotCrashWorker(t *testing.T) {
+	fake := &fakeSqsClient{messages: newTestMessages(1)}
+	handled := make(chan struct{})
+
+	c := New(Options{
+		QueueUrl: "https://example.com/queue",
+		HandleMessage: func(_ context.Context, _ *types.Message) error {
+			defer close(handled)
+			panic("boom")
+		},
+	})
+	c.sqsClient = fake
+
+	go c.Start()
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for handler to be invoked")
+	}
+
+	c.Stop()
+
+	if got := fake.deleted.Load(); got != 0 {
+		t.Fatalf("expected panicking handler to retain the message by default, deleted=%d", got)
+	}
+}
@@ -0,0 +1,67 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// startVisibilityExtender starts a background heartbeat that periodically
+// calls ChangeMessageVisibility for msg while the handler is running, so
+// long-running jobs don't exceed VisibilityTimeout and become visible to
+// other consumers. It returns a stop func that must be called once the
+// handler returns, and blocks until the heartbeat goroutine has exited.
+func (c *Consumer) startVisibilityExtender(msg *types.Message) func() {
+	if !c.extendEnabled {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		log := GetLogger("visibilityExtender").With().
+			Str("MessageId", aws.ToString(msg.MessageId)).Logger()
+		ticker := time.NewTicker(c.extendInterval)
+		defer ticker.Stop()
+
+		extensions := 0
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if c.maxExtensions > 0 && extensions >= c.maxExtensions {
+					log.Warn().Msgf("reached max visibility extensions (%d), giving up", c.maxExtensions)
+					return
+				}
+
+				_, err := c.sqsClient.ChangeMessageVisibility(
+					context.Background(),
+					&sqs.ChangeMessageVisibilityInput{
+						QueueUrl:          &c.queueUrl,
+						ReceiptHandle:     msg.ReceiptHandle,
+						VisibilityTimeout: int32(c.visibilityTimeout),
+					},
+				)
+				if err != nil {
+					log.Error().Err(err).Msgf("error extending message visibility, aborting extender")
+					return
+				}
+
+				extensions++
+				log.Debug().Msgf("extended visibility timeout (extension %d)", extensions)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}